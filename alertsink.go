@@ -0,0 +1,156 @@
+package meow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Alert describes a single state change (failure or recovery) reported to
+// an AlertSink.
+type Alert struct {
+	Identifier   string    `json:"identifier"`
+	URL          string    `json:"url"`
+	FailureCount int       `json:"failure_count"`
+	FirstFailure time.Time `json:"first_failure"`
+	LastStatus   int       `json:"last_status,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	Recovered    bool      `json:"recovered"`
+}
+
+// AlertSink delivers an Alert to an external system.
+type AlertSink interface {
+	Send(a Alert) error
+}
+
+// AlertSinkConfig is the JSON representation of a single alert sink,
+// stored per-endpoint in the alert_sinks Valkey hash field. Type selects
+// which of the type-specific fields apply.
+type AlertSinkConfig struct {
+	Type string `json:"type"`
+
+	// Webhook
+	URL string `json:"url,omitempty"`
+
+	// Email
+	SMTPAddr string   `json:"smtp_addr,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// Exec
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	// Cooldown rate-limits repeated failure alerts for the same
+	// endpoint, e.g. "15m". Empty means alert once per failure episode.
+	Cooldown string `json:"cooldown,omitempty"`
+}
+
+// BuildAlertSink turns a config entry into a ready-to-use AlertSink plus
+// its parsed cooldown (zero if none was set).
+func BuildAlertSink(cfg AlertSinkConfig) (AlertSink, time.Duration, error) {
+	var cooldown time.Duration
+	if cfg.Cooldown != "" {
+		var err error
+		cooldown, err = time.ParseDuration(cfg.Cooldown)
+		if err != nil {
+			return nil, 0, fmt.Errorf("parse cooldown %s: %v", cfg.Cooldown, err)
+		}
+	}
+
+	switch cfg.Type {
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, 0, fmt.Errorf("webhook sink requires url")
+		}
+		return &WebhookSink{URL: cfg.URL, Client: http.DefaultClient}, cooldown, nil
+	case "email":
+		if cfg.SMTPAddr == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, 0, fmt.Errorf("email sink requires smtp_addr, from and to")
+		}
+		return &EmailSink{SMTPAddr: cfg.SMTPAddr, From: cfg.From, To: cfg.To}, cooldown, nil
+	case "exec":
+		if cfg.Command == "" {
+			return nil, 0, fmt.Errorf("exec sink requires command")
+		}
+		return &ExecSink{Command: cfg.Command, Args: cfg.Args}, cooldown, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown alert sink type %q", cfg.Type)
+	}
+}
+
+// WebhookSink POSTs the alert as JSON to a configured URL.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookSink) Send(a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal alert: %v", err)
+	}
+	res, err := w.Client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post webhook %s: %v", w.URL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", w.URL, res.StatusCode)
+	}
+	return nil
+}
+
+// EmailSink sends the alert as a plain-text email via SMTP.
+type EmailSink struct {
+	SMTPAddr string
+	From     string
+	To       []string
+}
+
+func (e *EmailSink) Send(a Alert) error {
+	subject := fmt.Sprintf("%s is down", a.Identifier)
+	body := fmt.Sprintf("endpoint %s (%s) failed %d time(s) since %s\nlast status: %d\nlast error: %s",
+		a.Identifier, a.URL, a.FailureCount, a.FirstFailure.Format(time.RFC3339),
+		a.LastStatus, a.LastError)
+	if a.Recovered {
+		subject = fmt.Sprintf("%s has recovered", a.Identifier)
+		body = fmt.Sprintf("endpoint %s (%s) is back online", a.Identifier, a.URL)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n",
+		e.From, strings.Join(e.To, ", "), subject, body)
+	if err := smtp.SendMail(e.SMTPAddr, nil, e.From, e.To, []byte(msg)); err != nil {
+		return fmt.Errorf("send mail via %s: %v", e.SMTPAddr, err)
+	}
+	return nil
+}
+
+// ExecSink spawns a command with the alert passed via environment
+// variables, e.g. for custom paging integrations.
+type ExecSink struct {
+	Command string
+	Args    []string
+}
+
+func (e *ExecSink) Send(a Alert) error {
+	cmd := exec.Command(e.Command, e.Args...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("MEOW_IDENTIFIER=%s", a.Identifier),
+		fmt.Sprintf("MEOW_URL=%s", a.URL),
+		fmt.Sprintf("MEOW_FAILURE_COUNT=%d", a.FailureCount),
+		fmt.Sprintf("MEOW_FIRST_FAILURE=%s", a.FirstFailure.Format(time.RFC3339)),
+		fmt.Sprintf("MEOW_LAST_STATUS=%d", a.LastStatus),
+		fmt.Sprintf("MEOW_LAST_ERROR=%s", a.LastError),
+		fmt.Sprintf("MEOW_RECOVERED=%t", a.Recovered),
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("exec %s: %v", e.Command, err)
+	}
+	return nil
+}
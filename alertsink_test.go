@@ -0,0 +1,95 @@
+package meow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildAlertSinkWebhook(t *testing.T) {
+	sink, cooldown, err := BuildAlertSink(AlertSinkConfig{
+		Type:     "webhook",
+		URL:      "https://hooks.example.com",
+		Cooldown: "15m",
+	})
+	if err != nil {
+		t.Fatalf("BuildAlertSink: %v", err)
+	}
+	if _, ok := sink.(*WebhookSink); !ok {
+		t.Errorf("sink = %T, want *WebhookSink", sink)
+	}
+	if cooldown != 15*time.Minute {
+		t.Errorf("cooldown = %v, want 15m", cooldown)
+	}
+}
+
+func TestBuildAlertSinkWebhookRequiresURL(t *testing.T) {
+	if _, _, err := BuildAlertSink(AlertSinkConfig{Type: "webhook"}); err == nil {
+		t.Error("expected error for webhook sink without url")
+	}
+}
+
+func TestBuildAlertSinkEmailRequiresFields(t *testing.T) {
+	cases := []AlertSinkConfig{
+		{Type: "email"},
+		{Type: "email", SMTPAddr: "smtp.example.com:25"},
+		{Type: "email", SMTPAddr: "smtp.example.com:25", From: "meow@example.com"},
+	}
+	for _, cfg := range cases {
+		if _, _, err := BuildAlertSink(cfg); err == nil {
+			t.Errorf("BuildAlertSink(%+v): expected error for incomplete email config", cfg)
+		}
+	}
+}
+
+func TestBuildAlertSinkExecRequiresCommand(t *testing.T) {
+	if _, _, err := BuildAlertSink(AlertSinkConfig{Type: "exec"}); err == nil {
+		t.Error("expected error for exec sink without command")
+	}
+}
+
+func TestBuildAlertSinkUnknownType(t *testing.T) {
+	if _, _, err := BuildAlertSink(AlertSinkConfig{Type: "carrier-pigeon"}); err == nil {
+		t.Error("expected error for unknown sink type")
+	}
+}
+
+func TestBuildAlertSinkInvalidCooldown(t *testing.T) {
+	if _, _, err := BuildAlertSink(AlertSinkConfig{Type: "webhook", URL: "https://hooks.example.com", Cooldown: "not-a-duration"}); err == nil {
+		t.Error("expected error for unparseable cooldown")
+	}
+}
+
+func TestWebhookSinkSend(t *testing.T) {
+	var gotMethod, gotContentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotContentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	sink := &WebhookSink{URL: srv.URL, Client: http.DefaultClient}
+	if err := sink.Send(Alert{Identifier: "api"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if gotMethod != http.MethodPost {
+		t.Errorf("method = %s, want POST", gotMethod)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("content-type = %s, want application/json", gotContentType)
+	}
+}
+
+func TestWebhookSinkSendErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	sink := &WebhookSink{URL: srv.URL, Client: http.DefaultClient}
+	if err := sink.Send(Alert{Identifier: "api"}); err == nil {
+		t.Error("expected error for a >=300 response status")
+	}
+}
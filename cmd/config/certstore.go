@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// certStore holds the TLS certificate/key pair currently served, and
+// allows it to be swapped out via reload so operators can rotate
+// certificates (e.g. on SIGHUP) without restarting the listener and
+// losing in-flight probes.
+type certStore struct {
+	certPath, keyPath string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// newCertStore loads the cert/key pair at the given paths and returns a
+// store that serves it via GetCertificate.
+func newCertStore(certPath, keyPath string) (*certStore, error) {
+	cs := &certStore{certPath: certPath, keyPath: keyPath}
+	if err := cs.reload(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// reload re-reads the cert/key pair from disk and swaps it in atomically.
+// A failed reload leaves the previously loaded certificate in place.
+func (cs *certStore) reload() error {
+	cert, err := tls.LoadX509KeyPair(cs.certPath, cs.keyPath)
+	if err != nil {
+		return fmt.Errorf("load key pair %s/%s: %v", cs.certPath, cs.keyPath, err)
+	}
+	cs.mu.Lock()
+	cs.cert = &cert
+	cs.mu.Unlock()
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate.
+func (cs *certStore) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.cert, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/patrickbucher/meow"
+	"github.com/valkey-io/valkey-go"
+)
+
+// probeRecord is a single entry of an endpoint's status:<id> stream, as
+// returned by the history endpoint.
+type probeRecord struct {
+	ID         string `json:"id"`
+	Timestamp  string `json:"timestamp"`
+	DurationMs int64  `json:"duration_ms"`
+	Status     int    `json:"status"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+}
+
+func probeRecordFromFields(id string, fields map[string]string) (probeRecord, error) {
+	durationMs, err := strconv.ParseInt(fields["duration_ms"], 10, 64)
+	if err != nil {
+		return probeRecord{}, fmt.Errorf("parse duration_ms %s: %v", fields["duration_ms"], err)
+	}
+	status, err := strconv.Atoi(fields["status"])
+	if err != nil {
+		return probeRecord{}, fmt.Errorf("parse status %s: %v", fields["status"], err)
+	}
+	ok, err := strconv.ParseBool(fields["ok"])
+	if err != nil {
+		return probeRecord{}, fmt.Errorf("parse ok %s: %v", fields["ok"], err)
+	}
+	return probeRecord{
+		ID:         id,
+		Timestamp:  fields["timestamp"],
+		DurationMs: durationMs,
+		Status:     status,
+		OK:         ok,
+		Error:      fields["error"],
+	}, nil
+}
+
+// getEndpointHistory handles GET /endpoints/{id}/history?since=<RFC3339>&limit=<n>,
+// returning the raw status:<id> stream entries.
+func getEndpointHistory(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
+	log.Printf("GET %s from %s", r.URL, r.RemoteAddr)
+	identifier, err := extractEndpointIdentifierFrom(endpointHistoryPattern, r.URL.Path)
+	if err != nil {
+		log.Printf("extract endpoint identifier of %s: %v", r.URL, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	start := "-"
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			log.Printf("parse since=%s: %v", since, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		start = fmt.Sprintf("%d", t.UnixMilli())
+	}
+
+	limit := int64(100)
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.ParseInt(rawLimit, 10, 64)
+		if err != nil {
+			log.Printf("parse limit=%s: %v", rawLimit, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	ctx := context.Background()
+	entries, err := vk.Do(ctx, vk.B().Xrange().Key(fmt.Sprintf("status:%s", identifier)).
+		Start(start).End("+").Count(limit).Build()).AsXRange()
+	if err != nil {
+		log.Printf("xrange status:%s: %v", identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]probeRecord, 0, len(entries))
+	for _, entry := range entries {
+		record, err := probeRecordFromFields(entry.ID, entry.FieldValues)
+		if err != nil {
+			log.Printf("decode status:%s entry %s: %v", identifier, entry.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		records = append(records, record)
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		log.Printf("serialize history for %s: %v", identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+// endpointStats is the computed response of GET /endpoints/{id}/stats.
+// Window only scopes Samples/UptimePercent/MeanTimeBetweenFailS, which
+// come from the status:<id> stream; the LatencyP*MsLifetime fields come
+// from the endpoint's latency:<id> histogram instead, which has no
+// notion of a time window and always reflects the probe's full running
+// history. The "Lifetime" suffix and doc comment exist so that mismatch
+// is part of the API contract, not a surprise.
+type endpointStats struct {
+	Window               string  `json:"window"`
+	Samples              int     `json:"samples"`
+	UptimePercent        float64 `json:"uptime_percent"`
+	LatencyP50MsLifetime int64   `json:"p50_ms_lifetime"`
+	LatencyP95MsLifetime int64   `json:"p95_ms_lifetime"`
+	LatencyP99MsLifetime int64   `json:"p99_ms_lifetime"`
+	MeanTimeBetweenFailS float64 `json:"mean_time_between_failures_seconds"`
+}
+
+// latencyKey mirrors cmd/probe's snapshot key, so the stats endpoint reads
+// the same per-endpoint histogram the probe maintains and snapshots after
+// every probe.
+func latencyKey(identifier string) string {
+	return fmt.Sprintf("latency:%s", identifier)
+}
+
+// loadLatencyHistogram fetches and decodes the snapshotted histogram for
+// identifier, returning an empty one if no snapshot exists yet.
+func loadLatencyHistogram(ctx context.Context, vk valkey.Client, identifier string) (*meow.LatencyHistogram, error) {
+	histogram := &meow.LatencyHistogram{}
+	raw, err := vk.Do(ctx, vk.B().Get().Key(latencyKey(identifier)).Build()).AsBytes()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return histogram, nil
+		}
+		return nil, fmt.Errorf("get %s: %v", latencyKey(identifier), err)
+	}
+	if err := json.Unmarshal(raw, histogram); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", latencyKey(identifier), err)
+	}
+	return histogram, nil
+}
+
+// getEndpointStats handles GET /endpoints/{id}/stats?window=<duration>,
+// computing uptime and MTBF from the status:<id> stream within window,
+// and latency percentiles from the endpoint's lifetime latency:<id>
+// histogram. See endpointStats for why the two halves of the response
+// have different scopes.
+func getEndpointStats(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
+	log.Printf("GET %s from %s", r.URL, r.RemoteAddr)
+	identifier, err := extractEndpointIdentifierFrom(endpointStatsPattern, r.URL.Path)
+	if err != nil {
+		log.Printf("extract endpoint identifier of %s: %v", r.URL, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	window := 24 * time.Hour
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Printf("parse window=%s: %v", raw, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	ctx := context.Background()
+	start := fmt.Sprintf("%d", time.Now().Add(-window).UnixMilli())
+	entries, err := vk.Do(ctx, vk.B().Xrange().Key(fmt.Sprintf("status:%s", identifier)).
+		Start(start).End("+").Build()).AsXRange()
+	if err != nil {
+		log.Printf("xrange status:%s: %v", identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]probeRecord, 0, len(entries))
+	for _, entry := range entries {
+		record, err := probeRecordFromFields(entry.ID, entry.FieldValues)
+		if err != nil {
+			log.Printf("decode status:%s entry %s: %v", identifier, entry.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		records = append(records, record)
+	}
+
+	stats := endpointStats{Window: window.String(), Samples: len(records)}
+	if len(records) > 0 {
+		failures := 0
+		var failureTimes []time.Time
+		for _, record := range records {
+			if !record.OK {
+				failures++
+				if t, err := time.Parse(time.RFC3339Nano, record.Timestamp); err == nil {
+					failureTimes = append(failureTimes, t)
+				}
+			}
+		}
+		stats.UptimePercent = 100 * float64(len(records)-failures) / float64(len(records))
+		if len(failureTimes) > 1 {
+			sort.Slice(failureTimes, func(i, j int) bool { return failureTimes[i].Before(failureTimes[j]) })
+			span := failureTimes[len(failureTimes)-1].Sub(failureTimes[0])
+			stats.MeanTimeBetweenFailS = span.Seconds() / float64(len(failureTimes)-1)
+		}
+	}
+
+	histogram, err := loadLatencyHistogram(ctx, vk, identifier)
+	if err != nil {
+		log.Printf("load latency histogram for %s: %v", identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	stats.LatencyP50MsLifetime = histogram.Quantile(0.50).Milliseconds()
+	stats.LatencyP95MsLifetime = histogram.Quantile(0.95).Milliseconds()
+	stats.LatencyP99MsLifetime = histogram.Quantile(0.99).Milliseconds()
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		log.Printf("serialize stats for %s: %v", identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
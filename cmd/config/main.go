@@ -3,15 +3,21 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/patrickbucher/meow"
 	"github.com/valkey-io/valkey-go"
@@ -20,6 +26,19 @@ import (
 func main() {
 	addr := flag.String("addr", "0.0.0.0", "listen to address")
 	port := flag.Uint("port", 8000, "listen on port")
+	lameDuck := flag.Duration("lame-duck", 5*time.Second,
+		"time to wait for in-flight requests to finish before shutting down")
+	https := flag.Bool("https", false, "also listen for HTTPS on -https-port")
+	httpsPort := flag.Uint("https-port", 8443, "listen on port for HTTPS, if -https is set")
+	// -cert and -key are re-read on every SIGHUP (see certStore.reload),
+	// which happens after privileges have been dropped to -user. If the
+	// files are only root-readable, as is typical, every reload after
+	// the drop fails silently (logged, not fatal) and keeps serving the
+	// stale certificate. When combining -https with -user, make sure
+	// -cert and -key are readable by the -user account.
+	cert := flag.String("cert", "", "path to the TLS certificate, required if -https is set; must stay readable by -user for SIGHUP reloads to work")
+	key := flag.String("key", "", "path to the TLS private key, required if -https is set; must stay readable by -user for SIGHUP reloads to work")
+	user := flag.String("user", "", "unprivileged user (optionally \"user:group\") to drop to after binding")
 	flag.Parse()
 
 	log.SetOutput(os.Stderr)
@@ -39,12 +58,23 @@ func main() {
 	if err != nil {
 		log.Fatalf("create valkey client: %v", err)
 	}
-	defer client.Close()
 
-	http.HandleFunc("/endpoints/", func(w http.ResponseWriter, r *http.Request) {
+	if err := ensureEndpointIndex(context.Background(), client); err != nil {
+		log.Fatalf("ensure endpoint index: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/endpoints/", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			getEndpoint(w, r, client)
+			switch {
+			case endpointHistoryPattern.MatchString(r.URL.Path):
+				getEndpointHistory(w, r, client)
+			case endpointStatsPattern.MatchString(r.URL.Path):
+				getEndpointStats(w, r, client)
+			default:
+				getEndpoint(w, r, client)
+			}
 		case http.MethodPost:
 			postEndpoint(w, r, client)
 		case http.MethodDelete:
@@ -55,13 +85,109 @@ func main() {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
-	http.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
 		getEndpoints(w, r, client)
 	})
 
 	listenTo := fmt.Sprintf("%s:%d", *addr, *port)
+	srv := &http.Server{Addr: listenTo, Handler: mux}
+	listener, err := net.Listen("tcp", listenTo)
+	if err != nil {
+		log.Fatalf("listen on %s: %v", listenTo, err)
+	}
+
+	var tlsSrv *http.Server
+	var tlsListener net.Listener
+	var certs *certStore
+	if *https {
+		if *cert == "" || *key == "" {
+			log.Fatal("-cert and -key are required if -https is set")
+		}
+		certs, err = newCertStore(*cert, *key)
+		if err != nil {
+			log.Fatalf("load TLS certificate: %v", err)
+		}
+		listenToTLS := fmt.Sprintf("%s:%d", *addr, *httpsPort)
+		tlsSrv = &http.Server{
+			Addr:      listenToTLS,
+			Handler:   mux,
+			TLSConfig: &tls.Config{GetCertificate: certs.getCertificate},
+		}
+		tlsListener, err = net.Listen("tcp", listenToTLS)
+		if err != nil {
+			log.Fatalf("listen on %s: %v", listenToTLS, err)
+		}
+	}
+
+	// Sockets are bound; drop root privileges, if requested, before
+	// serving any requests.
+	if *user != "" {
+		if err := dropPrivileges(*user); err != nil {
+			log.Fatalf("drop privileges to %s: %v", *user, err)
+		}
+		log.Printf("dropped privileges to %s", *user)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			if certs == nil {
+				continue
+			}
+			if err := certs.reload(); err != nil {
+				log.Printf("reload TLS certificate: %v", err)
+				continue
+			}
+			log.Printf("reloaded TLS certificate from %s/%s", *cert, *key)
+		}
+	}()
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		s := <-signals
+		log.Printf("signal %v received, draining for up to %v", s, *lameDuck)
+		ctx, cancel := context.WithTimeout(context.Background(), *lameDuck)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown http: %v", err)
+		}
+		if tlsSrv != nil {
+			if err := tlsSrv.Shutdown(ctx); err != nil {
+				log.Printf("shutdown https: %v", err)
+			}
+		}
+	}()
+
+	var tlsDone chan struct{}
+	if tlsSrv != nil {
+		tlsDone = make(chan struct{})
+		go func() {
+			defer close(tlsDone)
+			log.Printf("listen to %s (https)", tlsSrv.Addr)
+			if err := tlsSrv.ServeTLS(tlsListener, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("serve https: %v", err)
+			}
+		}()
+	}
+
 	log.Printf("listen to %s", listenTo)
-	http.ListenAndServe(listenTo, nil)
+	serveErr := srv.Serve(listener)
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		log.Printf("serve: %v", serveErr)
+	}
+	if tlsDone != nil {
+		<-tlsDone
+	}
+	// Serve returns as soon as Shutdown starts; wait for the drain to
+	// finish before closing the Valkey client.
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		<-shutdownDone
+	}
+
+	client.Close()
 }
 
 func getEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
@@ -109,6 +235,13 @@ func getEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		return
 	}
 
+	alertSinks, err := parseAlertSinks(kvs["alert_sinks"])
+	if err != nil {
+		log.Printf("parse alert_sinks for %s: %v", key, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	payload := meow.EndpointPayload{
 		Identifier:   kvs["identifier"],
 		URL:          kvs["url"],
@@ -116,6 +249,7 @@ func getEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		StatusOnline: uint16(statusOnline),
 		Frequency:    kvs["frequency"],
 		FailAfter:    uint8(failAfter),
+		AlertSinks:   alertSinks,
 	}
 
 	data, err := json.Marshal(payload)
@@ -172,6 +306,13 @@ func postEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		status = http.StatusCreated
 	}
 
+	alertSinksJSON, err := json.Marshal(endpoint.AlertSinks)
+	if err != nil {
+		log.Printf("serialize alert_sinks for %s: %v", key, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	// Store the endpoint in Valkey using HSET
 	err = vk.Do(ctx, vk.B().Hset().Key(key).
 		FieldValue().
@@ -181,6 +322,7 @@ func postEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		FieldValue("status_online", strconv.FormatUint(uint64(endpoint.StatusOnline), 10)).
 		FieldValue("frequency", endpoint.Frequency.String()).
 		FieldValue("fail_after", strconv.FormatUint(uint64(endpoint.FailAfter), 10)).
+		FieldValue("alert_sinks", string(alertSinksJSON)).
 		Build()).Error()
 
 	if err != nil {
@@ -189,6 +331,15 @@ func postEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		return
 	}
 
+	// Keep the secondary index in sync so getEndpoints never has to fall
+	// back to a KEYS/SCAN sweep.
+	if err := vk.Do(ctx, vk.B().Sadd().Key(endpointsIndexKey).
+		Member(endpoint.Identifier).Build()).Error(); err != nil {
+		log.Printf("sadd %s %s: %v", endpointsIndexKey, endpoint.Identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(status)
 }
 
@@ -228,6 +379,13 @@ func deleteEndpoint(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		return
 	}
 
+	if err := vk.Do(ctx, vk.B().Srem().Key(endpointsIndexKey).
+		Member(identifier).Build()).Error(); err != nil {
+		log.Printf("srem %s %s: %v", endpointsIndexKey, identifier, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -242,29 +400,38 @@ func getEndpoints(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 
 	ctx := context.Background()
 
-	// Get all endpoint keys from Valkey
-	keys, err := vk.Do(ctx, vk.B().Keys().Pattern("endpoints:*").Build()).AsStrSlice()
+	// List known identifiers from the secondary index instead of an O(N)
+	// KEYS/SCAN sweep over endpoints:*.
+	identifiers, err := vk.Do(ctx, vk.B().Smembers().Key(endpointsIndexKey).Build()).AsStrSlice()
 	if err != nil {
-		log.Printf("get keys for endpoints:*: %v", err)
+		log.Printf("smembers %s: %v", endpointsIndexKey, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	payloads := make([]meow.EndpointPayload, 0)
 
-	// For each key, get the hash values
-	for _, key := range keys {
-		kvs, err := vk.Do(ctx, vk.B().Hgetall().Key(key).Build()).AsStrMap()
+	// Fetch all hashes in a single pipelined round-trip.
+	cmds := make([]valkey.Completed, len(identifiers))
+	for i, identifier := range identifiers {
+		cmds[i] = vk.B().Hgetall().Key(fmt.Sprintf("endpoints:%s", identifier)).Build()
+	}
+	for i, resp := range vk.DoMulti(ctx, cmds...) {
+		kvs, err := resp.AsStrMap()
 		if err != nil {
-			log.Printf("hgetall %s: %v", key, err)
+			log.Printf("hgetall endpoints:%s: %v", identifiers[i], err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		if len(kvs) == 0 {
+			// Index points at a hash that no longer exists; skip it.
+			continue
+		}
 
 		// Convert status_online to uint16
 		statusOnline, err := strconv.ParseUint(kvs["status_online"], 10, 16)
 		if err != nil {
-			log.Printf("parse status_online for %s: %v", key, err)
+			log.Printf("parse status_online for %s: %v", identifiers[i], err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -272,7 +439,14 @@ func getEndpoints(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 		// Convert fail_after to uint8
 		failAfter, err := strconv.ParseUint(kvs["fail_after"], 10, 8)
 		if err != nil {
-			log.Printf("parse fail_after for %s: %v", key, err)
+			log.Printf("parse fail_after for %s: %v", identifiers[i], err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		alertSinks, err := parseAlertSinks(kvs["alert_sinks"])
+		if err != nil {
+			log.Printf("parse alert_sinks for %s: %v", identifiers[i], err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -284,6 +458,7 @@ func getEndpoints(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 			StatusOnline: uint16(statusOnline),
 			Frequency:    kvs["frequency"],
 			FailAfter:    uint8(failAfter),
+			AlertSinks:   alertSinks,
 		}
 		payloads = append(payloads, payload)
 	}
@@ -297,6 +472,59 @@ func getEndpoints(w http.ResponseWriter, r *http.Request, vk valkey.Client) {
 	w.Write(data)
 }
 
+// endpointsIndexKey is a set of identifiers of all known endpoints,
+// kept in sync by postEndpoint/deleteEndpoint so listing endpoints never
+// needs a KEYS/SCAN sweep over endpoints:*.
+const endpointsIndexKey = "endpoints:index"
+
+// ensureEndpointIndex populates endpointsIndexKey from any pre-existing
+// endpoints:* hashes the first time the service starts against a Valkey
+// instance that doesn't have the index yet. It is a no-op once the index
+// exists.
+func ensureEndpointIndex(ctx context.Context, vk valkey.Client) error {
+	exists, err := vk.Do(ctx, vk.B().Exists().Key(endpointsIndexKey).Build()).AsInt64()
+	if err != nil {
+		return fmt.Errorf("check exists %s: %v", endpointsIndexKey, err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	log.Printf("%s not found, migrating from a one-time SCAN sweep", endpointsIndexKey)
+	var cursor uint64
+	identifiers := make([]string, 0)
+	for {
+		entry, err := vk.Do(ctx, vk.B().Scan().Cursor(cursor).
+			Match("endpoints:*").Count(100).Build()).AsScanEntry()
+		if err != nil {
+			return fmt.Errorf("scan endpoints:* at cursor %d: %v", cursor, err)
+		}
+		for _, key := range entry.Elements {
+			if key == endpointsIndexKey {
+				continue
+			}
+			identifiers = append(identifiers, key[len("endpoints:"):])
+		}
+		cursor = entry.Cursor
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if len(identifiers) == 0 {
+		// Nothing to migrate yet; postEndpoint will create the index as
+		// soon as the first endpoint is registered.
+		return nil
+	}
+
+	members := vk.B().Sadd().Key(endpointsIndexKey).Member(identifiers...).Build()
+	if err := vk.Do(ctx, members).Error(); err != nil {
+		return fmt.Errorf("sadd %s: %v", endpointsIndexKey, err)
+	}
+	log.Printf("migrated %d endpoint(s) into %s", len(identifiers), endpointsIndexKey)
+	return nil
+}
+
 const endpointIdentifierPatternRaw = "^/endpoints/([a-z][-a-z0-9]+)$"
 
 var endpointIdentifierPattern = regexp.MustCompile(endpointIdentifierPatternRaw)
@@ -309,3 +537,32 @@ func extractEndpointIdentifier(endpoint string) (string, error) {
 	}
 	return matches[1], nil
 }
+
+const endpointHistoryPatternRaw = "^/endpoints/([a-z][-a-z0-9]+)/history$"
+const endpointStatsPatternRaw = "^/endpoints/([a-z][-a-z0-9]+)/stats$"
+
+var (
+	endpointHistoryPattern = regexp.MustCompile(endpointHistoryPatternRaw)
+	endpointStatsPattern   = regexp.MustCompile(endpointStatsPatternRaw)
+)
+
+func extractEndpointIdentifierFrom(pattern *regexp.Regexp, path string) (string, error) {
+	matches := pattern.FindStringSubmatch(path)
+	if len(matches) == 0 {
+		return "", fmt.Errorf(`path "%s" does not match pattern "%s"`, path, pattern.String())
+	}
+	return matches[1], nil
+}
+
+// parseAlertSinks decodes the alert_sinks hash field, which holds a JSON
+// array of meow.AlertSinkConfig. An empty field means no sinks configured.
+func parseAlertSinks(raw string) ([]meow.AlertSinkConfig, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var sinks []meow.AlertSinkConfig
+	if err := json.Unmarshal([]byte(raw), &sinks); err != nil {
+		return nil, fmt.Errorf("unmarshal alert_sinks %s: %v", raw, err)
+	}
+	return sinks, nil
+}
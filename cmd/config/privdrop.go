@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// dropPrivileges switches the running process to the given unprivileged
+// user (and, if present, group) once all privileged work -- binding
+// low-numbered ports -- is done. userSpec is either a bare username/uid
+// ("nobody") or a "user:group" pair ("nobody:nogroup").
+func dropPrivileges(userSpec string) error {
+	userName, groupName, hasGroup := splitUserSpec(userSpec)
+
+	u, err := user.Lookup(userName)
+	if err != nil {
+		byID, idErr := user.LookupId(userName)
+		if idErr != nil {
+			return fmt.Errorf("lookup user %s: %v", userName, err)
+		}
+		u = byID
+	}
+	gid := u.Gid
+	if hasGroup {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return fmt.Errorf("lookup group %s: %v", groupName, err)
+		}
+		gid = g.Gid
+	}
+
+	gidNum, err := strconv.Atoi(gid)
+	if err != nil {
+		return fmt.Errorf("parse gid %s: %v", gid, err)
+	}
+	uidNum, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("parse uid %s: %v", u.Uid, err)
+	}
+
+	// Clear supplementary groups before dropping gid/uid: otherwise the
+	// process keeps whatever groups it inherited from its caller
+	// (typically root's), which defeats the point of dropping privileges.
+	if err := syscall.Setgroups([]int{gidNum}); err != nil {
+		return fmt.Errorf("setgroups %d: %v", gidNum, err)
+	}
+	// Drop the group first: once the uid is dropped, the process may no
+	// longer have permission to change its gid.
+	if err := syscall.Setgid(gidNum); err != nil {
+		return fmt.Errorf("setgid %d: %v", gidNum, err)
+	}
+	if err := syscall.Setuid(uidNum); err != nil {
+		return fmt.Errorf("setuid %d: %v", uidNum, err)
+	}
+	return nil
+}
+
+func splitUserSpec(spec string) (userName, groupName string, hasGroup bool) {
+	for i := 0; i < len(spec); i++ {
+		if spec[i] == ':' {
+			return spec[:i], spec[i+1:], true
+		}
+	}
+	return spec, "", false
+}
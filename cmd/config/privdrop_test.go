@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestSplitUserSpec(t *testing.T) {
+	cases := []struct {
+		spec         string
+		wantUser     string
+		wantGroup    string
+		wantHasGroup bool
+	}{
+		{"nobody", "nobody", "", false},
+		{"nobody:nogroup", "nobody", "nogroup", true},
+		{"", "", "", false},
+		{":nogroup", "", "nogroup", true},
+	}
+	for _, c := range cases {
+		userName, groupName, hasGroup := splitUserSpec(c.spec)
+		if userName != c.wantUser || groupName != c.wantGroup || hasGroup != c.wantHasGroup {
+			t.Errorf("splitUserSpec(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				c.spec, userName, groupName, hasGroup, c.wantUser, c.wantGroup, c.wantHasGroup)
+		}
+	}
+}
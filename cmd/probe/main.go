@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,6 +23,10 @@ import (
 )
 
 func main() {
+	reconcileInterval := flag.Duration("reconcile-interval", 15*time.Second,
+		"how often to re-poll the config service for added/changed/removed endpoints")
+	flag.Parse()
+
 	configURL, ok := os.LookupEnv("CONFIG_URL")
 	if !ok {
 		fmt.Fprintln(os.Stderr, "environment variable CONFIG_URL must be set")
@@ -62,82 +70,372 @@ func main() {
 	}
 	fmt.Fprintf(os.Stderr, "started logging to %s\n", logFilePath)
 
-	go monitor(endpoints, logFile)
+	monitorCtx, cancelMonitor := context.WithCancel(context.Background())
+	monitorDone := make(chan struct{})
+	go func() {
+		monitor(monitorCtx, configURL, endpoints, *reconcileInterval, logFile, client)
+		close(monitorDone)
+	}()
 
-	done := make(chan struct{})
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	s := <-signals
+	fmt.Fprintf(os.Stderr, "signal %v received\n", s)
+	cancelMonitor()
+	<-monitorDone
+	// TODO: now it would be a good time to archive logFilePath to S3
+	logFile.Close()
+}
+
+// alertJob pairs an alert with the sinks it should be dispatched to, so
+// the dispatcher goroutine doesn't need to re-resolve an endpoint's
+// configuration for every alert.
+type alertJob struct {
+	sinks []meow.AlertSink
+	alert meow.Alert
+}
+
+// probeSet tracks the endpoints currently being probed, so the
+// reconciliation loop can diff a freshly fetched endpoint list against
+// what's actually running.
+type probeSet struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	known   map[string]meow.Endpoint
+}
+
+func newProbeSet() *probeSet {
+	return &probeSet{
+		running: make(map[string]context.CancelFunc),
+		known:   make(map[string]meow.Endpoint),
+	}
+}
+
+func monitor(ctx context.Context, configURL string, initial []meow.Endpoint,
+	reconcileInterval time.Duration, logger *meow.LogFile, vk valkey.Client) {
+	messages := make(chan string)
+	alerts := make(chan alertJob, 64)
+	var wg sync.WaitGroup
+	set := newProbeSet()
+
+	startProbe := func(e meow.Endpoint) {
+		probeCtx, cancel := context.WithCancel(ctx)
+		set.mu.Lock()
+		set.running[e.Identifier] = cancel
+		set.known[e.Identifier] = e
+		set.mu.Unlock()
+		wg.Add(1)
+		go func(e meow.Endpoint) {
+			defer wg.Done()
+			probe(probeCtx, e, messages, alerts, vk)
+			set.mu.Lock()
+			delete(set.running, e.Identifier)
+			set.mu.Unlock()
+		}(e)
+	}
+
+	for _, endpoint := range initial {
+		startProbe(endpoint)
+	}
+
+	reconcileDone := make(chan struct{})
 	go func() {
-		s := <-signals
-		fmt.Fprintf(os.Stderr, "signal %v received\n", s)
-		logFile.Close()
-		// TODO: now it would be a good time to archive logFilePath to S3
-		done <- struct{}{}
+		defer close(reconcileDone)
+		reconcileEndpoints(ctx, configURL, reconcileInterval, set, startProbe, messages)
 	}()
 
-	<-done
-}
-
-func monitor(endpoints []meow.Endpoint, logger *meow.LogFile) {
-	probe := func(e meow.Endpoint, messages chan string) {
-		messages <- fmt.Sprintf("started probing %s every %v", e.Identifier, e.Frequency)
-		freq := time.NewTicker(e.Frequency)
-		errorCount := 0
-		lastStateOK := false
-		firstTry := true
-		alerted := false
-		for {
-			start := time.Now()
-			status, err := requestForStatus(e)
-			if err != nil {
-				// TODO: adjust log format
-				messages <- fmt.Sprintf("%c request failed: %v", meow.CrossMark, err)
-			}
-			end := time.Now()
-			duration := end.Sub(start)
-			stateOK := status == int(e.StatusOnline)
-			if stateOK {
-				if lastStateOK || firstTry {
-					// TODO: adjust log format
-					messages <- fmt.Sprintf("%c %s is online (took %v)",
-						meow.CatAvailable, e.Identifier, duration)
-				} else {
-					// TODO: adjust log format
-					messages <- fmt.Sprintf("%c %s is online again (took %v)",
-						meow.CatAvailableAgain, e.Identifier, duration)
+	go func() {
+		<-reconcileDone
+		wg.Wait()
+		close(messages)
+		close(alerts)
+	}()
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		for job := range alerts {
+			for _, sink := range job.sinks {
+				if err := sink.Send(job.alert); err != nil {
+					fmt.Fprintf(os.Stderr, "alert sink error for %s: %v\n", job.alert.Identifier, err)
 				}
-				lastStateOK = true
-				errorCount = 0
-				alerted = false
+			}
+		}
+	}()
+	for logMessage := range messages {
+		fmt.Fprintln(os.Stderr, logMessage)
+		logger.WriteLine(logMessage)
+	}
+	<-dispatcherDone
+}
+
+// reconcileEndpoints re-polls the config service on reconcileInterval and
+// diffs the result against set: new identifiers are started, vanished
+// ones are cancelled, and ones whose configuration changed (see
+// endpointConfigEqual) are restarted. It returns once ctx is cancelled.
+//
+// TODO: also subscribe to Valkey keyspace notifications on
+// __keyspace@28__:endpoints:* to react faster than the poll interval.
+func reconcileEndpoints(ctx context.Context, configURL string, reconcileInterval time.Duration,
+	set *probeSet, startProbe func(meow.Endpoint), messages chan string) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fetched, err := fetchEndpoints(configURL)
+		if err != nil {
+			messages <- fmt.Sprintf("reconcile: fetch endpoints: %v", err)
+			continue
+		}
+		fetchedByID := make(map[string]meow.Endpoint, len(fetched))
+		for _, e := range fetched {
+			fetchedByID[e.Identifier] = e
+		}
+
+		set.mu.Lock()
+		for id, cancel := range set.running {
+			e, stillConfigured := fetchedByID[id]
+			switch {
+			case !stillConfigured:
+				cancel()
+				delete(set.known, id)
+				messages <- fmt.Sprintf("reconcile: %s removed, stopping probe", id)
+			case !endpointConfigEqual(set.known[id], e):
+				cancel()
+				messages <- fmt.Sprintf("reconcile: %s changed, restarting probe", id)
+			}
+		}
+		set.mu.Unlock()
+
+		for id, e := range fetchedByID {
+			set.mu.Lock()
+			_, stillRunning := set.running[id]
+			set.mu.Unlock()
+			if !stillRunning {
+				startProbe(e)
+			}
+		}
+	}
+}
+
+// endpointConfigEqual compares the fields that matter for probing. Sinks
+// are resolved once, at the top of probe(), so AlertSinks has to be
+// included here too: without it, an operator updating an endpoint's
+// alert_sinks alone would have no effect until something else about the
+// endpoint also changed.
+func endpointConfigEqual(a, b meow.Endpoint) bool {
+	return a.URL.String() == b.URL.String() &&
+		a.Method == b.Method &&
+		a.StatusOnline == b.StatusOnline &&
+		a.Frequency == b.Frequency &&
+		a.FailAfter == b.FailAfter &&
+		reflect.DeepEqual(a.AlertSinks, b.AlertSinks)
+}
+
+func probe(ctx context.Context, e meow.Endpoint, messages chan string, alerts chan<- alertJob, vk valkey.Client) {
+	messages <- fmt.Sprintf("started probing %s every %v", e.Identifier, e.Frequency)
+	sinks, cooldown := buildAlertSinks(e, messages)
+	histogram := loadLatencyHistogram(ctx, vk, e.Identifier, messages)
+	freq := time.NewTicker(e.Frequency)
+	defer freq.Stop()
+	errorCount := 0
+	lastStateOK := false
+	firstTry := true
+	alerted := false
+	var firstFailure, lastAlertAt time.Time
+	for {
+		start := time.Now()
+		status, reqErr := requestForStatus(ctx, e)
+		if reqErr != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// TODO: adjust log format
+			messages <- fmt.Sprintf("%c request failed: %v", meow.CrossMark, reqErr)
+		}
+		end := time.Now()
+		duration := end.Sub(start)
+		stateOK := status == int(e.StatusOnline)
+		histogram.Add(duration)
+		// Snapshot on every probe rather than only at goroutine exit, so
+		// a long-lived endpoint's latency:<id> key (and the stats
+		// endpoint reading it) stays current instead of going stale for
+		// the service's entire normal runtime.
+		snapshotLatencyHistogram(vk, e.Identifier, histogram, messages)
+		recordProbeResult(ctx, vk, e.Identifier, start, duration, status, stateOK, reqErr, messages)
+		if stateOK {
+			if lastStateOK || firstTry {
+				// TODO: adjust log format
+				messages <- fmt.Sprintf("%c %s is online (took %v)",
+					meow.CatAvailable, e.Identifier, duration)
 			} else {
-				errorCount++
 				// TODO: adjust log format
-				messages <- fmt.Sprintf("%c %s is not online (%d times)",
-					meow.CatUnavailable, e.Identifier, errorCount)
-				if errorCount >= int(e.FailAfter) && !alerted {
-					// TODO: adjust log format
-					messages <- fmt.Sprintf("%c ALERT: %s is offline (%d failed attempts)",
-						meow.CatAlert, e.Identifier, e.FailAfter)
-					alerted = true
+				messages <- fmt.Sprintf("%c %s is online again (took %v)",
+					meow.CatAvailableAgain, e.Identifier, duration)
+			}
+			if alerted && len(sinks) > 0 {
+				dispatch(alerts, messages, e.Identifier, alertJob{
+					sinks: sinks,
+					alert: meow.Alert{
+						Identifier:   e.Identifier,
+						URL:          e.URL.String(),
+						FailureCount: errorCount,
+						FirstFailure: firstFailure,
+						Recovered:    true,
+					},
+				})
+			}
+			lastStateOK = true
+			errorCount = 0
+			alerted = false
+		} else {
+			if errorCount == 0 {
+				firstFailure = start
+			}
+			errorCount++
+			// TODO: adjust log format
+			messages <- fmt.Sprintf("%c %s is not online (%d times)",
+				meow.CatUnavailable, e.Identifier, errorCount)
+			if errorCount >= int(e.FailAfter) &&
+				(!alerted || (cooldown > 0 && time.Since(lastAlertAt) >= cooldown)) {
+				// TODO: adjust log format
+				messages <- fmt.Sprintf("%c ALERT: %s is offline (%d failed attempts)",
+					meow.CatAlert, e.Identifier, e.FailAfter)
+				lastErr := ""
+				if reqErr != nil {
+					lastErr = reqErr.Error()
+				}
+				if len(sinks) > 0 {
+					dispatch(alerts, messages, e.Identifier, alertJob{
+						sinks: sinks,
+						alert: meow.Alert{
+							Identifier:   e.Identifier,
+							URL:          e.URL.String(),
+							FailureCount: errorCount,
+							FirstFailure: firstFailure,
+							LastStatus:   status,
+							LastError:    lastErr,
+						},
+					})
 				}
-				lastStateOK = false
+				alerted = true
+				lastAlertAt = time.Now()
 			}
-			firstTry = false
-			<-freq.C
+			lastStateOK = false
+		}
+		firstTry = false
+		select {
+		case <-ctx.Done():
+			return
+		case <-freq.C:
 		}
 	}
-	messages := make(chan string)
-	for _, endpoint := range endpoints {
-		go probe(endpoint, messages)
+}
+
+// buildAlertSinks resolves an endpoint's configured alert sinks, logging
+// (but not failing probing over) any sink that fails to build. It returns
+// the resolved sinks and the longest cooldown among them.
+func buildAlertSinks(e meow.Endpoint, messages chan string) ([]meow.AlertSink, time.Duration) {
+	sinks := make([]meow.AlertSink, 0, len(e.AlertSinks))
+	var cooldown time.Duration
+	for _, cfg := range e.AlertSinks {
+		sink, sinkCooldown, err := meow.BuildAlertSink(cfg)
+		if err != nil {
+			messages <- fmt.Sprintf("build alert sink %s for %s: %v", cfg.Type, e.Identifier, err)
+			continue
+		}
+		sinks = append(sinks, sink)
+		if sinkCooldown > cooldown {
+			cooldown = sinkCooldown
+		}
 	}
-	for logMessage := range messages {
-		fmt.Fprintln(os.Stderr, logMessage)
-		logger.WriteLine(logMessage)
+	return sinks, cooldown
+}
+
+// dispatch enqueues an alert job without blocking probing: if the
+// dispatcher is backed up, the alert is dropped and logged rather than
+// stalling the probe loop.
+func dispatch(alerts chan<- alertJob, messages chan string, identifier string, job alertJob) {
+	select {
+	case alerts <- job:
+	default:
+		messages <- fmt.Sprintf("alert channel full, dropping alert for %s", identifier)
+	}
+}
+
+// statusStreamMaxLen bounds each endpoint's status:<id> stream so probe
+// history doesn't grow unboundedly.
+const statusStreamMaxLen = 1000
+
+// recordProbeResult appends a single probe outcome to the endpoint's
+// capped status:<id> stream, so it can be queried later via the config
+// service's history/stats routes.
+func recordProbeResult(ctx context.Context, vk valkey.Client, identifier string,
+	at time.Time, duration time.Duration, status int, ok bool, reqErr error, messages chan string) {
+	errString := ""
+	if reqErr != nil {
+		errString = reqErr.Error()
+	}
+	key := fmt.Sprintf("status:%s", identifier)
+	err := vk.Do(ctx, vk.B().Xadd().Key(key).
+		Maxlen().Almost().Threshold(strconv.Itoa(statusStreamMaxLen)).
+		Id("*").
+		FieldValue().
+		FieldValue("timestamp", at.Format(time.RFC3339Nano)).
+		FieldValue("duration_ms", strconv.FormatInt(duration.Milliseconds(), 10)).
+		FieldValue("status", strconv.Itoa(status)).
+		FieldValue("ok", strconv.FormatBool(ok)).
+		FieldValue("error", errString).
+		Build()).Error()
+	if err != nil {
+		messages <- fmt.Sprintf("xadd %s: %v", key, err)
+	}
+}
+
+// latencyKey is where an endpoint's in-memory latency histogram is
+// snapshotted so a restart doesn't lose the percentile window.
+func latencyKey(identifier string) string {
+	return fmt.Sprintf("latency:%s", identifier)
+}
+
+// loadLatencyHistogram restores a previously snapshotted histogram, or
+// returns a fresh one if none was saved yet.
+func loadLatencyHistogram(ctx context.Context, vk valkey.Client, identifier string, messages chan string) *meow.LatencyHistogram {
+	histogram := &meow.LatencyHistogram{}
+	raw, err := vk.Do(ctx, vk.B().Get().Key(latencyKey(identifier)).Build()).AsBytes()
+	if err != nil {
+		if !valkey.IsValkeyNil(err) {
+			messages <- fmt.Sprintf("load latency snapshot for %s: %v", identifier, err)
+		}
+		return histogram
+	}
+	if err := json.Unmarshal(raw, histogram); err != nil {
+		messages <- fmt.Sprintf("parse latency snapshot for %s: %v", identifier, err)
+		return &meow.LatencyHistogram{}
 	}
+	return histogram
 }
 
-func requestForStatus(e meow.Endpoint) (int, error) {
-	req, err := http.NewRequest(e.Method, e.URL.String(), nil)
+// snapshotLatencyHistogram persists the current histogram so the next
+// startup can resume the percentile window instead of starting empty.
+func snapshotLatencyHistogram(vk valkey.Client, identifier string, histogram *meow.LatencyHistogram, messages chan string) {
+	data, err := json.Marshal(histogram)
+	if err != nil {
+		messages <- fmt.Sprintf("marshal latency snapshot for %s: %v", identifier, err)
+		return
+	}
+	if err := vk.Do(context.Background(), vk.B().Set().Key(latencyKey(identifier)).
+		Value(string(data)).Build()).Error(); err != nil {
+		messages <- fmt.Sprintf("snapshot latency histogram for %s: %v", identifier, err)
+	}
+}
+
+func requestForStatus(ctx context.Context, e meow.Endpoint) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, e.Method, e.URL.String(), nil)
 	if err != nil {
 		return 0, fmt.Errorf("prepare request: %s %s %s: %v", e.Identifier, e.Method, e.URL, err)
 	}
@@ -150,27 +448,38 @@ func requestForStatus(e meow.Endpoint) (int, error) {
 }
 
 func mustFetchEndpoints(configURL string) []meow.Endpoint {
+	endpoints, err := fetchEndpoints(configURL)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return endpoints
+}
+
+// fetchEndpoints fetches and parses the current endpoint list from the
+// config service. Used both for the initial fetch at startup and for the
+// periodic reconciliation against what's currently being probed.
+func fetchEndpoints(configURL string) ([]meow.Endpoint, error) {
 	endpoints := make([]meow.Endpoint, 0)
 	configEndpoint := fmt.Sprintf("%s/endpoints", configURL)
 	res, err := http.Get(configEndpoint)
 	if err != nil {
-		log.Fatalf("fetch endpoints from %s: %v", configEndpoint, err)
+		return nil, fmt.Errorf("fetch endpoints from %s: %v", configEndpoint, err)
 	}
 	defer res.Body.Close()
 	payloads := make([]meow.EndpointPayload, 0)
 	buf := bytes.NewBufferString("")
 	if _, err := io.Copy(buf, res.Body); err != nil {
-		log.Fatalf("copy body from result of %s: %v", configEndpoint, err)
+		return nil, fmt.Errorf("copy body from result of %s: %v", configEndpoint, err)
 	}
 	if err := json.Unmarshal(buf.Bytes(), &payloads); err != nil {
-		log.Fatalf("unmarshal JSON payload: %v", err)
+		return nil, fmt.Errorf("unmarshal JSON payload: %v", err)
 	}
 	for _, payload := range payloads {
 		endpoint, err := meow.EndpointFromPayload(payload)
 		if err != nil {
-			log.Fatalf("convert payload %v to endpoint: %v", payload, err)
+			return nil, fmt.Errorf("convert payload %v to endpoint: %v", payload, err)
 		}
 		endpoints = append(endpoints, *endpoint)
 	}
-	return endpoints
+	return endpoints, nil
 }
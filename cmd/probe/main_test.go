@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/patrickbucher/meow"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse url %s: %v", raw, err)
+	}
+	return u
+}
+
+func TestEndpointConfigEqual(t *testing.T) {
+	base := meow.Endpoint{
+		Identifier:   "api",
+		URL:          mustParseURL(t, "https://example.com/health"),
+		Method:       "GET",
+		StatusOnline: 200,
+		Frequency:    30 * time.Second,
+		FailAfter:    3,
+		AlertSinks:   []meow.AlertSinkConfig{{Type: "webhook", URL: "https://hooks.example.com"}},
+	}
+
+	t.Run("identical is equal", func(t *testing.T) {
+		other := base
+		if !endpointConfigEqual(base, other) {
+			t.Error("expected equal endpoints to compare equal")
+		}
+	})
+
+	t.Run("alert sinks differ", func(t *testing.T) {
+		other := base
+		other.AlertSinks = nil
+		if endpointConfigEqual(base, other) {
+			t.Error("expected different AlertSinks to compare unequal, since sinks are only resolved at probe start")
+		}
+	})
+
+	t.Run("url differs", func(t *testing.T) {
+		other := base
+		other.URL = mustParseURL(t, "https://example.com/other")
+		if endpointConfigEqual(base, other) {
+			t.Error("expected different URLs to compare unequal")
+		}
+	})
+
+	t.Run("method differs", func(t *testing.T) {
+		other := base
+		other.Method = "HEAD"
+		if endpointConfigEqual(base, other) {
+			t.Error("expected different methods to compare unequal")
+		}
+	})
+
+	t.Run("frequency differs", func(t *testing.T) {
+		other := base
+		other.Frequency = 60 * time.Second
+		if endpointConfigEqual(base, other) {
+			t.Error("expected different frequencies to compare unequal")
+		}
+	})
+
+	t.Run("fail after differs", func(t *testing.T) {
+		other := base
+		other.FailAfter = 5
+		if endpointConfigEqual(base, other) {
+			t.Error("expected different fail_after to compare unequal")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package meow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Endpoint describes a single HTTP(S) resource that is probed periodically
+// by the monitor.
+type Endpoint struct {
+	Identifier   string
+	URL          *url.URL
+	Method       string
+	StatusOnline uint16
+	Frequency    time.Duration
+	FailAfter    uint8
+	AlertSinks   []AlertSinkConfig
+}
+
+// EndpointPayload is the JSON wire format exchanged with the config
+// service. Unlike Endpoint, its URL and Frequency are plain strings, so it
+// round-trips through HTTP bodies without custom marshalling. AlertSinks
+// is a list of structs, which the config service stores in its Valkey
+// hash as a JSON-encoded string.
+type EndpointPayload struct {
+	Identifier   string            `json:"identifier"`
+	URL          string            `json:"url"`
+	Method       string            `json:"method"`
+	StatusOnline uint16            `json:"status_online"`
+	Frequency    string            `json:"frequency"`
+	FailAfter    uint8             `json:"fail_after"`
+	AlertSinks   []AlertSinkConfig `json:"alert_sinks,omitempty"`
+}
+
+// EndpointFromJSON parses a single EndpointPayload from raw JSON and
+// converts it into an Endpoint.
+func EndpointFromJSON(raw string) (*Endpoint, error) {
+	var payload EndpointPayload
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return nil, fmt.Errorf("unmarshal endpoint payload: %v", err)
+	}
+	return EndpointFromPayload(payload)
+}
+
+// EndpointFromPayload converts the wire format into an Endpoint, parsing
+// its URL and frequency fields.
+func EndpointFromPayload(payload EndpointPayload) (*Endpoint, error) {
+	u, err := url.Parse(payload.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url %s: %v", payload.URL, err)
+	}
+	frequency, err := time.ParseDuration(payload.Frequency)
+	if err != nil {
+		return nil, fmt.Errorf("parse frequency %s: %v", payload.Frequency, err)
+	}
+	return &Endpoint{
+		Identifier:   payload.Identifier,
+		URL:          u,
+		Method:       payload.Method,
+		StatusOnline: payload.StatusOnline,
+		Frequency:    frequency,
+		FailAfter:    payload.FailAfter,
+		AlertSinks:   payload.AlertSinks,
+	}, nil
+}
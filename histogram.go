@@ -0,0 +1,55 @@
+package meow
+
+import (
+	"math"
+	"time"
+)
+
+// numLatencyBuckets bounds LatencyHistogram to roughly 1ms .. 24 days,
+// in log2-spaced milliseconds -- plenty of headroom for HTTP probes.
+// Longer samples are counted in the last bucket.
+const numLatencyBuckets = 32
+
+// LatencyHistogram is a small, fixed-size histogram of probe latencies.
+// It trades exactness for a constant memory footprint, which is what
+// lets the monitor keep one per endpoint for the lifetime of the
+// process. Bucket i holds samples in [2^(i-1)ms, 2^i ms).
+type LatencyHistogram struct {
+	Buckets [numLatencyBuckets]uint64 `json:"buckets"`
+	Count   uint64                    `json:"count"`
+}
+
+// Add records a single latency sample.
+func (h *LatencyHistogram) Add(d time.Duration) {
+	ms := d.Milliseconds()
+	bucket := 0
+	if ms > 0 {
+		bucket = int(math.Log2(float64(ms))) + 1
+	}
+	if bucket >= numLatencyBuckets {
+		bucket = numLatencyBuckets - 1
+	}
+	h.Buckets[bucket]++
+	h.Count++
+}
+
+// Quantile estimates the q-th quantile (0..1) as a duration, using the
+// upper bound of the bucket the quantile falls into. Returns 0 if no
+// samples have been recorded.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	target := uint64(math.Ceil(q * float64(h.Count)))
+	if target == 0 {
+		target = 1
+	}
+	var cumulative uint64
+	for i, c := range h.Buckets {
+		cumulative += c
+		if cumulative >= target {
+			return time.Duration(math.Pow(2, float64(i))) * time.Millisecond
+		}
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package meow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramQuantileEmpty(t *testing.T) {
+	h := &LatencyHistogram{}
+	if got := h.Quantile(0.50); got != 0 {
+		t.Errorf("Quantile on empty histogram = %v, want 0", got)
+	}
+}
+
+func TestLatencyHistogramAddAndQuantile(t *testing.T) {
+	h := &LatencyHistogram{}
+	for _, ms := range []int{1, 1, 5, 20, 100, 100, 100, 500, 1000, 4000} {
+		h.Add(time.Duration(ms) * time.Millisecond)
+	}
+	if h.Count != 10 {
+		t.Fatalf("Count = %d, want 10", h.Count)
+	}
+
+	// Quantile is a bucket-upper-bound estimate, not an exact order
+	// statistic, so assert it lands in the right ballpark rather than
+	// matching a sorted-sample percentile exactly.
+	if p50 := h.Quantile(0.50); p50 < 100*time.Millisecond || p50 > 200*time.Millisecond {
+		t.Errorf("p50 = %v, want roughly 100-200ms", p50)
+	}
+	if p99 := h.Quantile(0.99); p99 < 1*time.Second {
+		t.Errorf("p99 = %v, want at least 1s", p99)
+	}
+}
+
+func TestLatencyHistogramAddClampsToLastBucket(t *testing.T) {
+	h := &LatencyHistogram{}
+	h.Add(365 * 24 * time.Hour)
+	if h.Buckets[numLatencyBuckets-1] != 1 {
+		t.Errorf("extreme latency sample should land in the last bucket, got buckets=%v", h.Buckets)
+	}
+}
@@ -0,0 +1,62 @@
+package meow
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// LogFile is an append-only, line-buffered log file used by the probe
+// binary to persist monitoring output. It is safe for concurrent use:
+// WriteLine and Close share a mutex so a shutdown can never close the
+// underlying file while a write is in flight.
+type LogFile struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewLogFile creates (or truncates) the file at path and wraps it for
+// buffered line writes.
+func NewLogFile(path string) (*LogFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create log file %s: %v", path, err)
+	}
+	return &LogFile{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// WriteLine appends line followed by a newline and flushes immediately, so
+// readers tailing the file see it right away.
+func (l *LogFile) WriteLine(line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("write to closed log file")
+	}
+	if _, err := fmt.Fprintln(l.w, line); err != nil {
+		return fmt.Errorf("write line: %v", err)
+	}
+	return l.w.Flush()
+}
+
+// Close flushes any buffered output and closes the underlying file. It is
+// safe to call concurrently with WriteLine: callers must still ensure all
+// writers have stopped submitting lines first, since a write racing a
+// close after the file has been closed returns an error rather than
+// panicking.
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	flushErr := l.w.Flush()
+	closeErr := l.file.Close()
+	l.file = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
@@ -0,0 +1,11 @@
+package meow
+
+// Symbols prefixed to the human-readable lines the monitor writes to its
+// log file and to stderr.
+const (
+	CrossMark         = '✗'
+	CatAvailable      = '🐈'
+	CatAvailableAgain = '😺'
+	CatUnavailable    = '🙀'
+	CatAlert          = '🚨'
+)